@@ -11,6 +11,7 @@ package main
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"strconv"
@@ -25,75 +26,412 @@ func main() {
  Eval / Apply
 */
 
+var panicOnException = false //re-panic instead of converting a crash to an exception
+
+//a deferred (exp, en) pair for a tail position, so eval's trampoline can step it
+//without recursing
+type thunk struct {
+	exp scmo
+	en  *env
+}
+
+//drives the trampoline: steps eval1 and loops on a returned thunk instead of
+//recursing, so tail calls run in constant Go stack space
 func eval(expression scmo, en *env) (value scmo) {
+	defer func() {
+		if r := recover(); r != nil {
+			if panicOnException {
+				panic(r)
+			}
+			value = exception(fmt.Sprint(r))
+		}
+	}()
+	for {
+		value = eval1(expression, en)
+		if t, ok := value.(thunk); ok {
+			expression, en = t.exp, t.en
+			continue
+		}
+		return value
+	}
+}
+
+//evaluates one step; tail positions (if's branch, begin/cond's last expr, a
+//user-lambda application) return a thunk instead of recursing
+func eval1(expression scmo, en *env) (value scmo) {
 	switch e := expression.(type) {
 	case number:
-		value = e
+		return e
+	case integer:
+		return e
+	case string:
+		return e
+	case bool:
+		return e
 	case symbol:
-		value = en.Find(e).vars[e]
+		if idx := strings.Index(string(e), "::"); idx >= 0 {
+			return resolveQualified(string(e[:idx]), symbol(e[idx+2:]))
+		}
+		found, err := en.Find(e)
+		if err != nil {
+			return exception(err.Error())
+		}
+		return found.vars[e]
 	case []scmo:
 		switch e[0] {
 		case symbol("quote"):
-			value = e[1]
+			return e[1]
 		case symbol("if"):
-			if eval(e[1], en).(bool) {
-				value = eval(e[2], en)
-			} else {
-				value = eval(e[3], en)
+			cond := eval(e[1], en)
+			if ex, ok := cond.(exception); ok {
+				return ex
 			}
+			if cond.(bool) {
+				return thunk{e[2], en}
+			}
+			return thunk{e[3], en}
 		case symbol("set!"):
 			v := e[1].(symbol)
-			en.Find(v).vars[v] = eval(e[2], en)
-			value = "ok"
+			found, err := en.Find(v)
+			if err != nil {
+				return exception(err.Error())
+			}
+			rhs := eval(e[2], en)
+			if ex, ok := rhs.(exception); ok {
+				return ex
+			}
+			found.vars[v] = rhs
+			return "ok"
 		case symbol("define"):
-			en.vars[e[1].(symbol)] = eval(e[2], en)
-			value = "ok"
+			rhs := eval(e[2], en)
+			if ex, ok := rhs.(exception); ok {
+				return ex
+			}
+			en.vars[e[1].(symbol)] = rhs
+			return "ok"
 		case symbol("lambda"):
-			value = proc{e[1], e[2], en}
+			params, predicates := parseParams(e[1])
+			return proc{params, e[2], en, predicates}
+		case symbol("macro"):
+			return macro{e[1], e[2], en}
+		case symbol("define-macro"):
+			rhs := eval(e[2], en)
+			if ex, ok := rhs.(exception); ok {
+				return ex
+			}
+			en.vars[e[1].(symbol)] = rhs
+			return "ok"
+		case symbol("quasiquote"):
+			return evalQuasiquote(e[1], en)
+		case symbol("module"):
+			name := string(e[1].(symbol))
+			modEnv := &env{make(vars), &globalenv}
+			result := evalBody(e[2:], modEnv)
+			if ex, ok := result.(exception); ok {
+				return ex
+			}
+			namespaces[name] = modEnv
+			return "ok"
+		case symbol("import"):
+			name := string(e[1].(symbol))
+			if _, ok := namespaces[name]; !ok {
+				return exception(fmt.Sprintf("no such module: %s", name))
+			}
+			alias := name
+			if len(e) == 4 && e[2] == symbol("as") {
+				alias = string(e[3].(symbol))
+			}
+			altnamespaces[alias] = name
+			return "ok"
+		case symbol("load"):
+			pathVal := eval(e[1], en)
+			if ex, ok := pathVal.(exception); ok {
+				return ex
+			}
+			path, ok := pathVal.(string)
+			if !ok {
+				return exception("load: expected a string path")
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return exception(err.Error())
+			}
+			return eval(read("(begin "+string(data)+")"), en)
 		case symbol("begin"):
-			for _, i := range e[1:] {
-				value = eval(i, en)
+			return evalBodyTail(e[1:], en)
+		case symbol("cond"):
+			result, matched := evalCond(e[1:], en)
+			if !matched {
+				return exception("cond: no clause matched")
 			}
+			return result
+		case symbol("raise"):
+			return raise(eval(e[1], en))
+		case symbol("guard"):
+			spec := e[1].([]scmo)
+			exVar := spec[0].(symbol)
+			result := evalBody(e[2:], en)
+			if ex, ok := result.(exception); ok {
+				//bind the message as a plain string, not an exception: an
+				//exception value flowing through an ordinary eval would
+				//otherwise be mistaken for a still-propagating raise.
+				genv := &env{vars{exVar: string(ex)}, en}
+				result, matched := evalCond(spec[1:], genv)
+				if !matched {
+					return ex
+				}
+				return result
+			}
+			return result
 		default:
+			if sym, ok := e[0].(symbol); ok {
+				if found, err := en.Find(sym); err == nil {
+					if m, ok := found.vars[sym].(macro); ok {
+						expansion := expand(m, e[1:])
+						if ex, ok := expansion.(exception); ok {
+							return ex
+						}
+						return eval(expansion, en)
+					}
+				}
+			}
 			operands := e[1:]
 			values := make([]scmo, len(operands))
 			for i, x := range operands {
-				values[i] = eval(x, en)
+				v := eval(x, en)
+				if ex, ok := v.(exception); ok {
+					return ex
+				}
+				values[i] = v
+			}
+			head := eval(e[0], en)
+			if ex, ok := head.(exception); ok {
+				return ex
+			}
+			if p, ok := head.(proc); ok {
+				calleeEnv, err := bindParams(p.params, values, p.en)
+				if err != nil {
+					return exception(err.Error())
+				}
+				if ex := checkPredicates(p, calleeEnv); ex != nil {
+					return ex
+				}
+				return thunk{p.body, calleeEnv}
 			}
-			value = apply(eval(e[0], en), values)
+			return apply(head, values)
 		}
 	default:
-		log.Println("Unknown expression type - EVAL", e)
+		return exception(fmt.Sprintf("unknown expression type: %v", e))
+	}
+}
+
+func evalBody(body []scmo, en *env) (value scmo) {
+	for _, i := range body {
+		value = eval(i, en)
+		if _, ok := value.(exception); ok {
+			return value
+		}
 	}
 	return
 }
 
+func evalBodyTail(body []scmo, en *env) scmo {
+	if len(body) == 0 {
+		return nil
+	}
+	for _, i := range body[:len(body)-1] {
+		v := eval(i, en)
+		if _, ok := v.(exception); ok {
+			return v
+		}
+	}
+	return thunk{body[len(body)-1], en}
+}
+
+func evalCond(clauses []scmo, en *env) (result scmo, matched bool) {
+	for _, c := range clauses {
+		clause := c.([]scmo)
+		if clause[0] == symbol("else") {
+			return evalBodyTail(clause[1:], en), true
+		}
+		test := eval(clause[0], en)
+		if ex, ok := test.(exception); ok {
+			return ex, true
+		}
+		if test.(bool) {
+			return evalBodyTail(clause[1:], en), true
+		}
+	}
+	return nil, false
+}
+
+func raise(v scmo) exception {
+	switch v := v.(type) {
+	case exception:
+		return v
+	case string:
+		return exception(v)
+	default:
+		return exception(String(v))
+	}
+}
+
+func bindParams(params scmo, args []scmo, outer *env) (*env, error) {
+	en := &env{make(vars), outer}
+	switch params := params.(type) {
+	case []scmo:
+		if len(args) != len(params) {
+			return nil, fmt.Errorf("wrong number of arguments: expected %d, got %d", len(params), len(args))
+		}
+		for i, param := range params {
+			en.vars[param.(symbol)] = args[i]
+		}
+	default:
+		en.vars[params.(symbol)] = args
+	}
+	return en, nil
+}
+
 func apply(procedure scmo, args []scmo) (value scmo) {
 	switch p := procedure.(type) {
 	case func(...scmo) scmo:
 		value = p(args...)
 	case proc:
-		en := &env{make(vars), p.en}
-		switch params := p.params.(type) {
+		calleeEnv, err := bindParams(p.params, args, p.en)
+		if err != nil {
+			value = exception(err.Error())
+		} else if ex := checkPredicates(p, calleeEnv); ex != nil {
+			value = ex
+		} else {
+			value = eval(p.body, calleeEnv)
+		}
+	default:
+		value = exception(fmt.Sprintf("not a procedure: %v", p))
+	}
+	return
+}
+
+type proc struct {
+	params, body scmo
+	en           *env
+	predicates   map[symbol]symbol //param name -> predicate name, for typed (name predicate?) params
+}
+
+//splits out any predicates attached to (name predicate?) params
+func parseParams(paramsExpr scmo) (scmo, map[symbol]symbol) {
+	list, ok := paramsExpr.([]scmo)
+	if !ok {
+		return paramsExpr, nil
+	}
+	names := make([]scmo, len(list))
+	var predicates map[symbol]symbol
+	for i, p := range list {
+		switch p := p.(type) {
 		case []scmo:
-			for i, param := range params {
-				en.vars[param.(symbol)] = args[i]
+			name := p[0].(symbol)
+			names[i] = name
+			if predicates == nil {
+				predicates = make(map[symbol]symbol)
 			}
+			predicates[name] = p[1].(symbol)
 		default:
-			en.vars[params.(symbol)] = args
+			names[i] = p
+		}
+	}
+	return scmo(names), predicates
+}
+
+func checkPredicates(p proc, en *env) scmo {
+	if len(p.predicates) == 0 {
+		return nil
+	}
+	params := p.params.([]scmo)
+	for i, param := range params {
+		name := param.(symbol)
+		predName, ok := p.predicates[name]
+		if !ok {
+			continue
+		}
+		predFound, err := en.Find(predName)
+		if err != nil {
+			return exception(err.Error())
 		}
-		value = eval(p.body, en)
+		arg := en.vars[name]
+		result := apply(predFound.vars[predName], []scmo{arg})
+		if ex, ok := result.(exception); ok {
+			return ex
+		}
+		if ok, isBool := result.(bool); !isBool || !ok {
+			return exception(fmt.Sprintf("arg %d: expected %s, got %s", i, predName, typeName(arg)))
+		}
+	}
+	return nil
+}
+
+func typeName(v scmo) string {
+	switch v.(type) {
+	case number, integer:
+		return "number"
+	case symbol:
+		return "symbol"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case []scmo:
+		return "pair"
+	case func(...scmo) scmo, proc:
+		return "procedure"
 	default:
-		log.Println("Unknown procedure type - APPLY", p)
+		return fmt.Sprintf("%T", v)
 	}
-	return
 }
 
-type proc struct {
+//like proc, but operands are bound unevaluated and the body's result is eval'd again
+type macro struct {
 	params, body scmo
 	en           *env
 }
 
+func expand(m macro, operands []scmo) scmo {
+	en, err := bindParams(m.params, operands, m.en)
+	if err != nil {
+		return exception(err.Error())
+	}
+	return eval(m.body, en)
+}
+
+func evalQuasiquote(expr scmo, en *env) scmo {
+	list, ok := expr.([]scmo)
+	if !ok {
+		return expr
+	}
+	if len(list) == 2 && list[0] == symbol("unquote") {
+		return eval(list[1], en)
+	}
+	result := make([]scmo, 0, len(list))
+	for _, item := range list {
+		if sub, ok := item.([]scmo); ok && len(sub) == 2 && sub[0] == symbol("unquote-splicing") {
+			spliced := eval(sub[1], en)
+			if ex, ok := spliced.(exception); ok {
+				return ex
+			}
+			if sl, ok := spliced.([]scmo); ok {
+				result = append(result, sl...)
+				continue
+			}
+			result = append(result, spliced)
+			continue
+		}
+		val := evalQuasiquote(item, en)
+		if ex, ok := val.(exception); ok {
+			return ex
+		}
+		result = append(result, val)
+	}
+	return scmo(result)
+}
+
 /*
  Environments
 */
@@ -104,12 +442,29 @@ type env struct {
 	outer *env
 }
 
-func (e *env) Find(s symbol) *env {
+func (e *env) Find(s symbol) (*env, error) {
 	if _, ok := e.vars[s]; ok {
-		return e
-	} else {
-		return e.outer.Find(s)
+		return e, nil
 	}
+	if e.outer == nil {
+		return nil, fmt.Errorf("unbound symbol: %s", s)
+	}
+	return e.outer.Find(s)
+}
+
+func resolveQualified(pkg string, name symbol) scmo {
+	if real, ok := altnamespaces[pkg]; ok {
+		pkg = real
+	}
+	nsEnv, ok := namespaces[pkg]
+	if !ok {
+		return exception(fmt.Sprintf("no such module: %s", pkg))
+	}
+	found, err := nsEnv.Find(name)
+	if err != nil {
+		return exception(err.Error())
+	}
+	return found.vars[name]
 }
 
 /*
@@ -118,41 +473,44 @@ func (e *env) Find(s symbol) *env {
 
 var globalenv env
 
+var namespaces = map[string]*env{}       //module name -> its environment
+var altnamespaces = map[string]string{} //import alias -> real module name
+
 func init() {
 	globalenv = env{
 		vars{ //aka an incomplete set of compiled-in functions
 			symbol("#t"): true,
 			symbol("#f"): false,
 			symbol("+"): func(a ...scmo) scmo {
-				v := a[0].(number)
-				for _, i := range a[1:] {
-					v += i.(number)
-				}
-				return v
+				return arith(a, func(x, y float64) float64 { return x + y })
 			},
 			symbol("-"): func(a ...scmo) scmo {
-				v := a[0].(number)
-				for _, i := range a[1:] {
-					v -= i.(number)
-				}
-				return v
+				return arith(a, func(x, y float64) float64 { return x - y })
 			},
 			symbol("*"): func(a ...scmo) scmo {
-				v := a[0].(number)
-				for _, i := range a[1:] {
-					v *= i.(number)
-				}
-				return v
+				return arith(a, func(x, y float64) float64 { return x * y })
 			},
 			symbol("/"): func(a ...scmo) scmo {
-				v := a[0].(number)
-				for _, i := range a[1:] {
-					v /= i.(number)
+				acc, allInteger := numVal(a[0])
+				for _, x := range a[1:] {
+					v, wasInteger := numVal(x)
+					if v == 0 {
+						return exception("division by zero")
+					}
+					allInteger = allInteger && wasInteger
+					acc /= v
 				}
-				return v
+				//unlike +/-/*, integer division isn't generally exact, so
+				//only collapse to an integer when it truly divided evenly.
+				if allInteger && acc == float64(int64(acc)) {
+					return integer(int64(acc))
+				}
+				return number(acc)
 			},
 			symbol("<="): func(a ...scmo) scmo {
-				return a[0].(number) <= a[1].(number)
+				x, _ := numVal(a[0])
+				y, _ := numVal(a[1])
+				return x <= y
 			},
 			symbol("equal?"): func(a ...scmo) scmo {
 				return a[0] == a[1]
@@ -166,6 +524,36 @@ func init() {
 			symbol("cdr"): func(a ...scmo) scmo {
 				return a[0].([]scmo)[1:]
 			},
+			symbol("number?"): func(a ...scmo) scmo {
+				switch a[0].(type) {
+				case number, integer:
+					return true
+				}
+				return false
+			},
+			symbol("symbol?"): func(a ...scmo) scmo {
+				_, ok := a[0].(symbol)
+				return ok
+			},
+			symbol("pair?"): func(a ...scmo) scmo {
+				l, ok := a[0].([]scmo)
+				return ok && len(l) > 0
+			},
+			symbol("null?"): func(a ...scmo) scmo {
+				l, ok := a[0].([]scmo)
+				return ok && len(l) == 0
+			},
+			symbol("procedure?"): func(a ...scmo) scmo {
+				switch a[0].(type) {
+				case func(...scmo) scmo, proc:
+					return true
+				}
+				return false
+			},
+			symbol("boolean?"): func(a ...scmo) scmo {
+				_, ok := a[0].(bool)
+				return ok
+			},
 			symbol("list"): eval(read(
 				"(lambda z z)"),
 				&globalenv),
@@ -173,52 +561,392 @@ func init() {
 		nil}
 }
 
+func numVal(x scmo) (value float64, wasInteger bool) {
+	switch v := x.(type) {
+	case integer:
+		return float64(v), true
+	case number:
+		return float64(v), false
+	}
+	panic(fmt.Sprintf("not a number: %v", x))
+}
+
+func arith(a []scmo, f func(x, y float64) float64) scmo {
+	acc, allInteger := numVal(a[0])
+	for _, x := range a[1:] {
+		v, wasInteger := numVal(x)
+		allInteger = allInteger && wasInteger
+		acc = f(acc, v)
+	}
+	if allInteger {
+		return integer(int64(acc))
+	}
+	return number(acc)
+}
+
 /*
  Parsing
 */
 type scmo interface{} //scheme objects are e.g. symbols, numbers, expressions, procedures, lists, ...
-type symbol string     //symbols are golang strings
-type number float64    //constant numbers float64
+type symbol string    //symbols are golang strings
+type number float64   //floating point numbers
+type integer int64    //integers, kept distinct from number so arithmetic can preserve integer-ness
+type exception string //an error value that bubbles up through eval instead of crashing
 
 func read(s string) (expression scmo) {
-	tokens := tokenize(s)
-	return readFrom(&tokens)
-}
-
-//Syntactic Analysis
-func readFrom(tokens *[]string) (expression scmo) {
-	if len(*tokens) == 0 {
-		log.Print("unexpected EOF while reading")
-	}
-	token := (*tokens)[0]
-	//pop first element from tokens
-	*tokens = (*tokens)[1:]
-	switch token {
-	case "(": //a list begins
-		L := make([]scmo, 0)
-		for (*tokens)[0] != ")" {
-			L = append(L, readFrom(tokens))
-		}
-		*tokens = (*tokens)[1:]
-		return L
-	case ")":
-		log.Print("unexpected )")
+	p := &parser{lex: newLexer(strings.NewReader(s))}
+	expression, err := p.read()
+	if err != nil {
+		log.Println("read:", err)
 		return nil
-	default: //an atom occurs
-		if f, err := strconv.ParseFloat(token, 64); err == nil {
-			return number(f) //numbers become float64
-		} else {
-			return symbol(token) //others stay string
+	}
+	return expression
+}
+
+/*
+ Syntactic Analysis
+*/
+type parser struct {
+	lex *lexer
+}
+
+func (p *parser) read() (scmo, error) {
+	t, err := p.lex.Next()
+	if err != nil {
+		return nil, err
+	}
+	return p.readToken(t)
+}
+
+func (p *parser) readToken(t token) (scmo, error) {
+	switch t.kind {
+	case openParen:
+		list := make([]scmo, 0)
+		for {
+			nt, err := p.lex.Next()
+			if err != nil {
+				return nil, fmt.Errorf("unexpected EOF while reading")
+			}
+			if nt.kind == closeParen {
+				return scmo(list), nil
+			}
+			elem, err := p.readToken(nt)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, elem)
+		}
+	case closeParen:
+		return nil, fmt.Errorf("unexpected )")
+	case quoteToken:
+		inner, err := p.read()
+		if err != nil {
+			return nil, err
+		}
+		return []scmo{symbol(quoteName(t.value)), inner}, nil
+	case stringToken:
+		return t.value, nil
+	case integerToken:
+		n, err := strconv.ParseInt(t.value, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		return integer(n), nil
+	case floatToken:
+		f, err := strconv.ParseFloat(t.value, 64)
+		if err != nil {
+			return nil, err
+		}
+		return number(f), nil
+	case symbolToken:
+		return symbol(t.value), nil
+	default:
+		return nil, fmt.Errorf("unexpected token %q", t.value)
+	}
+}
+
+func quoteName(mark string) string {
+	switch mark {
+	case "'":
+		return "quote"
+	case "`":
+		return "quasiquote"
+	case ",":
+		return "unquote"
+	case ",@":
+		return "unquote-splicing"
+	}
+	return "quote"
+}
+
+/*
+ Lexical Analysis
+
+ A streaming, state-machine lexer modeled on Rob Pike's "Lexical Scanning
+ in Go": each state is a function that consumes runes from the underlying
+ io.RuneReader and returns the state to run next. A state emits a token by
+ calling l.emit, at which point Next() returns it to the caller.
+*/
+
+type tokenKind int
+
+const (
+	openParen tokenKind = iota
+	closeParen
+	symbolToken
+	integerToken
+	floatToken
+	stringToken
+	quoteToken
+	commentToken
+)
+
+type token struct {
+	value string
+	kind  tokenKind
+}
+
+type stateFn func(*lexer) (stateFn, error)
+
+type lexer struct {
+	r       io.RuneReader
+	buf     []rune
+	pending []token
+}
+
+func newLexer(r io.Reader) *lexer {
+	return &lexer{r: bufio.NewReader(r)}
+}
+
+func (l *lexer) readRune() (rune, error) {
+	ch, _, err := l.r.ReadRune()
+	return ch, err
+}
+
+func (l *lexer) unreadRune() {
+	if u, ok := l.r.(interface{ UnreadRune() error }); ok {
+		u.UnreadRune()
+	}
+}
+
+func (l *lexer) emit(kind tokenKind) {
+	if kind != commentToken {
+		l.pending = append(l.pending, token{string(l.buf), kind})
+	}
+	l.buf = l.buf[:0]
+}
+
+func (l *lexer) Next() (token, error) {
+	state := stateFn(lexWhitespace)
+	for len(l.pending) == 0 {
+		next, err := state(l)
+		if err != nil {
+			return token{}, err
+		}
+		state = next
+	}
+	t := l.pending[0]
+	l.pending = l.pending[1:]
+	return t, nil
+}
+
+func isDigit(ch rune) bool { return ch >= '0' && ch <= '9' }
+
+func isDelimiter(ch rune) bool {
+	switch ch {
+	case ' ', '\t', '\n', '\r', '(', ')', '"', ';', '\'', '`', ',':
+		return true
+	}
+	return false
+}
+
+func lexWhitespace(l *lexer) (stateFn, error) {
+	for {
+		ch, err := l.readRune()
+		if err != nil {
+			return nil, io.EOF
+		}
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			continue
+		case ch == '(':
+			l.buf = append(l.buf, ch)
+			return lexOpenParen, nil
+		case ch == ')':
+			l.buf = append(l.buf, ch)
+			return lexCloseParen, nil
+		case ch == ';':
+			return lexComment, nil
+		case ch == '"':
+			return lexString, nil
+		case ch == '\'' || ch == '`' || ch == ',':
+			l.buf = append(l.buf, ch)
+			return lexQuote, nil
+		case ch == '+' || ch == '-':
+			l.buf = append(l.buf, ch)
+			return lexSign, nil
+		case isDigit(ch):
+			l.buf = append(l.buf, ch)
+			return lexNumber, nil
+		default:
+			l.buf = append(l.buf, ch)
+			return lexSymbol, nil
+		}
+	}
+}
+
+//disambiguates a leading +/- between a signed number and a bare symbol like "+"
+func lexSign(l *lexer) (stateFn, error) {
+	ch, err := l.readRune()
+	if err != nil {
+		l.emit(symbolToken)
+		return nil, nil
+	}
+	if isDigit(ch) {
+		l.buf = append(l.buf, ch)
+		return lexNumber, nil
+	}
+	if isDelimiter(ch) {
+		l.unreadRune()
+		l.emit(symbolToken)
+		return lexWhitespace, nil
+	}
+	l.buf = append(l.buf, ch)
+	return lexSymbol, nil
+}
+
+func lexOpenParen(l *lexer) (stateFn, error) {
+	l.emit(openParen)
+	return lexWhitespace, nil
+}
+
+func lexCloseParen(l *lexer) (stateFn, error) {
+	l.emit(closeParen)
+	return lexWhitespace, nil
+}
+
+func lexQuote(l *lexer) (stateFn, error) {
+	if l.buf[0] == ',' {
+		ch, err := l.readRune()
+		if err == nil && ch == '@' {
+			l.buf = append(l.buf, ch)
+		} else if err == nil {
+			l.unreadRune()
+		}
+	}
+	l.emit(quoteToken)
+	return lexWhitespace, nil
+}
+
+func lexComment(l *lexer) (stateFn, error) {
+	for {
+		ch, err := l.readRune()
+		if err != nil {
+			return nil, io.EOF
+		}
+		if ch == '\n' {
+			return lexWhitespace, nil
+		}
+	}
+}
+
+func lexString(l *lexer) (stateFn, error) {
+	for {
+		ch, err := l.readRune()
+		if err != nil {
+			return nil, fmt.Errorf("unexpected EOF while reading a string literal")
+		}
+		switch ch {
+		case '"':
+			l.emit(stringToken)
+			return lexWhitespace, nil
+		case '\\':
+			return lexStringEsc, nil
+		default:
+			l.buf = append(l.buf, ch)
+		}
+	}
+}
+
+func lexStringEsc(l *lexer) (stateFn, error) {
+	ch, err := l.readRune()
+	if err != nil {
+		return nil, fmt.Errorf("unexpected EOF while reading a string literal")
+	}
+	switch ch {
+	case 'n':
+		l.buf = append(l.buf, '\n')
+	case 't':
+		l.buf = append(l.buf, '\t')
+	case '"':
+		l.buf = append(l.buf, '"')
+	case '\\':
+		l.buf = append(l.buf, '\\')
+	default:
+		l.buf = append(l.buf, ch)
+	}
+	return lexString, nil
+}
+
+func lexNumber(l *lexer) (stateFn, error) {
+	for {
+		ch, err := l.readRune()
+		if err != nil {
+			l.emit(integerToken)
+			return nil, nil
+		}
+		switch {
+		case ch == '.':
+			l.buf = append(l.buf, ch)
+			return lexFloat, nil
+		case isDigit(ch):
+			l.buf = append(l.buf, ch)
+		case isDelimiter(ch):
+			l.unreadRune()
+			l.emit(integerToken)
+			return lexWhitespace, nil
+		default:
+			l.buf = append(l.buf, ch)
+			return lexSymbol, nil
 		}
 	}
 }
 
-//Lexical Analysis
-func tokenize(s string) []string {
-	return strings.Split(
-		strings.Replace(strings.Replace(s, "(", "( ",
-			-1), ")", " )",
-			-1), " ")
+func lexFloat(l *lexer) (stateFn, error) {
+	for {
+		ch, err := l.readRune()
+		if err != nil {
+			l.emit(floatToken)
+			return nil, nil
+		}
+		switch {
+		case isDigit(ch):
+			l.buf = append(l.buf, ch)
+		case isDelimiter(ch):
+			l.unreadRune()
+			l.emit(floatToken)
+			return lexWhitespace, nil
+		default:
+			l.buf = append(l.buf, ch)
+			return lexSymbol, nil
+		}
+	}
+}
+
+func lexSymbol(l *lexer) (stateFn, error) {
+	for {
+		ch, err := l.readRune()
+		if err != nil {
+			l.emit(symbolToken)
+			return nil, nil
+		}
+		if isDelimiter(ch) {
+			l.unreadRune()
+			l.emit(symbolToken)
+			return lexWhitespace, nil
+		}
+		l.buf = append(l.buf, ch)
+	}
 }
 
 /*
@@ -244,6 +972,10 @@ func Repl() {
 		fmt.Print("> ")
 		if input, err := reader.ReadString('\n'); err == nil {
 			ans := eval(read(input[:len(input)-1]), &globalenv)
+			if ex, ok := ans.(exception); ok {
+				fmt.Println("error:", string(ex))
+				continue
+			}
 			globalenv.vars[symbol("ans")] = ans
 			fmt.Println("==>", String(ans))
 		} else {
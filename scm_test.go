@@ -0,0 +1,199 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func lexAll(s string) ([]token, error) {
+	l := newLexer(strings.NewReader(s))
+	var tokens []token
+	for {
+		t, err := l.Next()
+		if err == io.EOF {
+			return tokens, nil
+		}
+		if err != nil {
+			return tokens, err
+		}
+		tokens = append(tokens, t)
+	}
+}
+
+func TestLexerNestedLists(t *testing.T) {
+	tokens, err := lexAll("(1 (2 3) 4)")
+	if err != nil {
+		t.Fatalf("lexAll: %v", err)
+	}
+	want := []tokenKind{openParen, integerToken, openParen, integerToken, integerToken, closeParen, integerToken, closeParen}
+	if len(tokens) != len(want) {
+		t.Fatalf("got %d tokens, want %d: %v", len(tokens), len(want), tokens)
+	}
+	for i, k := range want {
+		if tokens[i].kind != k {
+			t.Errorf("token %d: got kind %v, want %v", i, tokens[i].kind, k)
+		}
+	}
+}
+
+func TestLexerStringEscapes(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{`"plain"`, "plain"},
+		{`"a\nb"`, "a\nb"},
+		{`"a\tb"`, "a\tb"},
+		{`"a\"b"`, `a"b`},
+		{`"a\\b"`, `a\b`},
+	}
+	for _, c := range cases {
+		tokens, err := lexAll(c.in)
+		if err != nil {
+			t.Fatalf("lexAll(%q): %v", c.in, err)
+		}
+		if len(tokens) != 1 || tokens[0].kind != stringToken {
+			t.Fatalf("lexAll(%q) = %v, want single stringToken", c.in, tokens)
+		}
+		if tokens[0].value != c.want {
+			t.Errorf("lexAll(%q).value = %q, want %q", c.in, tokens[0].value, c.want)
+		}
+	}
+}
+
+func TestLexerEOFMidString(t *testing.T) {
+	if _, err := lexAll(`"unterminated`); err == nil {
+		t.Fatalf("expected an error for a string literal with no closing quote")
+	}
+}
+
+func TestParserUnbalancedParens(t *testing.T) {
+	cases := []string{"(1 2", ")", "(1 (2 3)"}
+	for _, src := range cases {
+		p := &parser{lex: newLexer(strings.NewReader(src))}
+		if _, err := p.read(); err == nil {
+			t.Errorf("parsing %q: expected an error", src)
+		}
+	}
+}
+
+func evalString(en *env, s string) scmo {
+	return eval(read(s), en)
+}
+
+func newTestEnv() *env {
+	return &env{make(vars), &globalenv}
+}
+
+func TestExceptions(t *testing.T) {
+	cases := []struct {
+		name string
+		src  string
+	}{
+		{"divide by zero", "(/ 1 0)"},
+		{"arity mismatch", "(begin (define f (lambda (a b) (+ a b))) (f 1))"},
+		{"unbound symbol", "nosuchsymbol"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			en := newTestEnv()
+			if _, ok := evalString(en, c.src).(exception); !ok {
+				t.Errorf("%s: expected an exception", c.src)
+			}
+		})
+	}
+}
+
+func TestNestedGuard(t *testing.T) {
+	en := newTestEnv()
+	got := evalString(en, `(guard (e1 (#t (list 'outer e1))) (guard (e2 (#f e2)) (raise "inner-error")))`)
+	want := "(outer inner-error)"
+	if s := String(got); s != want {
+		t.Errorf("nested guard: got %q, want %q", s, want)
+	}
+}
+
+func TestMacros(t *testing.T) {
+	en := newTestEnv()
+	evalString(en, `(define-macro when (macro (test body) `+"`"+`(if ,test ,body #f)))`)
+	evalString(en, `(define-macro unless (macro (test body) `+"`"+`(if ,test #f ,body)))`)
+	evalString(en, `(define-macro let (macro (bindings body)
+		`+"`"+`((lambda (,(car (car bindings))) ,body) ,(car (cdr (car bindings))))))`)
+
+	cases := []struct {
+		name, src, want string
+	}{
+		{"when true", "(when (<= 1 2) 42)", "42"},
+		{"when false", "(when (<= 2 1) 42)", "false"},
+		{"unless true", "(unless (<= 1 2) 42)", "false"},
+		{"unless false", "(unless (<= 2 1) 42)", "42"},
+		{"let", "(let ((x 5)) (+ x 1))", "6"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := String(evalString(en, c.src)); got != c.want {
+				t.Errorf("%s: got %q, want %q", c.src, got, c.want)
+			}
+		})
+	}
+}
+
+func TestTailCallOptimization(t *testing.T) {
+	en := newTestEnv()
+	evalString(en, "(define loop (lambda (n) (if (<= n 0) 'done (loop (- n 1)))))")
+	if got := String(evalString(en, "(loop 1000000)")); got != "done" {
+		t.Fatalf("1,000,000-iteration tail loop: got %q, want \"done\"", got)
+	}
+
+	evalString(en, "(define even? (lambda (n) (if (<= n 0) #t (odd? (- n 1)))))")
+	evalString(en, "(define odd? (lambda (n) (if (<= n 0) #f (even? (- n 1)))))")
+	if got := String(evalString(en, "(even? 100000)")); got != "true" {
+		t.Errorf("mutual recursion: (even? 100000) = %q, want \"true\"", got)
+	}
+	if got := String(evalString(en, "(odd? 100000)")); got != "false" {
+		t.Errorf("mutual recursion: (odd? 100000) = %q, want \"false\"", got)
+	}
+}
+
+func TestTypedParams(t *testing.T) {
+	en := newTestEnv()
+	evalString(en, "(define f (lambda ((n number?) m) (+ n m)))")
+
+	if got := String(evalString(en, "(f 1 2)")); got != "3" {
+		t.Errorf("mixed typed/untyped params: (f 1 2) = %q, want \"3\"", got)
+	}
+
+	if _, ok := evalString(en, "(f 'x 2)").(exception); !ok {
+		t.Errorf("(f 'x 2): expected a predicate-failure exception")
+	}
+
+	got := String(evalString(en, "(guard (e (#t e)) (f 'x 2))"))
+	want := "arg 0: expected number?, got symbol"
+	if got != want {
+		t.Errorf("predicate failure through guard: got %q, want %q", got, want)
+	}
+}
+
+func TestModules(t *testing.T) {
+	en := newTestEnv()
+	evalString(en, "(module mod1 (define val 1))")
+	evalString(en, "(module mod2 (define val 2))")
+
+	if got := String(evalString(en, "mod1::val")); got != "1" {
+		t.Errorf("mod1::val = %q, want \"1\" (collision with mod2::val)", got)
+	}
+	if got := String(evalString(en, "mod2::val")); got != "2" {
+		t.Errorf("mod2::val = %q, want \"2\" (collision with mod1::val)", got)
+	}
+
+	evalString(en, "(module math (define sqrt2 (lambda (x) x)))")
+	if got := String(evalString(en, "(math::sqrt2 9)")); got != "9" {
+		t.Errorf("cross-module call math::sqrt2: got %q, want \"9\"", got)
+	}
+
+	evalString(en, "(import math as m)")
+	if got := String(evalString(en, "(m::sqrt2 4)")); got != "4" {
+		t.Errorf("cross-module call via import alias m::sqrt2: got %q, want \"4\"", got)
+	}
+}